@@ -0,0 +1,172 @@
+// Command lc3gen generates the LC-3 operand-decoding struct and
+// instruction dispatch table consumed by package isa, the same way
+// cmd/compile/internal/ssa/gen generates architecture opcode tables: a
+// small DSL (isa/ops.json) describes each instruction's mnemonic,
+// opcode and bit-field layout, and this tool emits the mechanical
+// decode/dispatch code so exec.go only has to hand-write semantics.
+//
+// Usage: go run ./cmd/lc3gen -ops isa/ops.json -out isa/zinstr.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"sort"
+	"text/template"
+)
+
+// opSpec is one entry of the ops.json DSL.
+type opSpec struct {
+	Mnemonic string   `json:"mnemonic"`
+	Opcode   uint16   `json:"opcode"`
+	Fields   []string `json:"fields"`
+}
+
+// field describes how to decode one named operand out of a raw
+// instruction word, and the Go type it decodes to.
+type field struct {
+	Name string
+	Type string
+	Expr string
+}
+
+// fieldVocabulary is the fixed set of operand fields the LC-3 encoding
+// can produce; ops.json entries select which of these apply to a given
+// instruction.
+var fieldVocabulary = map[string]field{
+	"DR":         {Name: "DR", Type: "Register", Expr: "Register((instr >> 9) & 0x7)"},
+	"SR":         {Name: "SR", Type: "Register", Expr: "Register((instr >> 9) & 0x7)"},
+	"SR1":        {Name: "SR1", Type: "Register", Expr: "Register((instr >> 6) & 0x7)"},
+	"SR2":        {Name: "SR2", Type: "Register", Expr: "Register(instr & 0x7)"},
+	"BaseR":      {Name: "BaseR", Type: "Register", Expr: "Register((instr >> 6) & 0x7)"},
+	"NZP":        {Name: "NZP", Type: "uint16", Expr: "(instr >> 9) & 0x7"},
+	"IsImm":      {Name: "IsImm", Type: "bool", Expr: "(instr>>5)&0x1 == 0x1"},
+	"Imm5":       {Name: "Imm5", Type: "uint16", Expr: "SignExtend(instr&0x1F, 5)"},
+	"PCOffset9":  {Name: "PCOffset9", Type: "uint16", Expr: "SignExtend(instr&0x1FF, 9)"},
+	"PCOffset11": {Name: "PCOffset11", Type: "uint16", Expr: "SignExtend(instr&0x7FF, 11)"},
+	"Offset6":    {Name: "Offset6", Type: "uint16", Expr: "SignExtend(instr&0x3F, 6)"},
+	"Trapvect8":  {Name: "Trapvect8", Type: "uint16", Expr: "instr & 0xFF"},
+	"IsJSR":      {Name: "IsJSR", Type: "bool", Expr: "(instr>>11)&0x1 == 0x1"},
+}
+
+const tmplSrc = `// Code generated by cmd/lc3gen from ops.json; DO NOT EDIT.
+
+package isa
+
+// Operands is every field any LC-3 instruction can decode out of a raw
+// instruction word. Exec functions only read the fields relevant to
+// their own opcode.
+type Operands struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}}
+{{- end}}
+}
+
+// DecodeOperands extracts every Operands field from instr.
+func DecodeOperands(instr uint16) Operands {
+	return Operands{
+{{- range .Fields}}
+		{{.Name}}: {{.Expr}},
+{{- end}}
+	}
+}
+
+// Table is the instruction dispatch table: Lookup finds the entry whose
+// Mask/Match selects a fetched word, and calls its Exec.
+var Table = []Instruction{
+{{- range .Ops}}
+	{Name: "{{.Mnemonic}}", Mask: 0xF000, Match: 0x{{printf "%04X" .Match}}, Exec: exec{{.Mnemonic}}},
+{{- end}}
+}
+`
+
+func main() {
+	opsPath := flag.String("ops", "ops.json", "path to the instruction DSL (JSON)")
+	outPath := flag.String("out", "zinstr.go", "path to write the generated Go source")
+	flag.Parse()
+
+	raw, err := os.ReadFile(*opsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "lc3gen:", err)
+		os.Exit(1)
+	}
+
+	var ops []opSpec
+	if err := json.Unmarshal(raw, &ops); err != nil {
+		fmt.Fprintln(os.Stderr, "lc3gen:", err)
+		os.Exit(1)
+	}
+
+	fields, err := collectFields(ops)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "lc3gen:", err)
+		os.Exit(1)
+	}
+
+	type tmplOp struct {
+		Mnemonic string
+		Match    uint16
+	}
+
+	tmplOps := make([]tmplOp, len(ops))
+	for i, op := range ops {
+		tmplOps[i] = tmplOp{Mnemonic: op.Mnemonic, Match: op.Opcode << 12}
+	}
+
+	tmpl := template.Must(template.New("zinstr").Parse(tmplSrc))
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, struct {
+		Fields []field
+		Ops    []tmplOp
+	}{Fields: fields, Ops: tmplOps}); err != nil {
+		fmt.Fprintln(os.Stderr, "lc3gen:", err)
+		os.Exit(1)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "lc3gen:", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(*outPath, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "lc3gen:", err)
+		os.Exit(1)
+	}
+}
+
+// collectFields returns the union of fields referenced across every op,
+// in a stable order, resolved against fieldVocabulary.
+func collectFields(ops []opSpec) ([]field, error) {
+	seen := map[string]bool{}
+	var names []string
+
+	for _, op := range ops {
+		for _, name := range op.Fields {
+			if seen[name] {
+				continue
+			}
+
+			if _, ok := fieldVocabulary[name]; !ok {
+				return nil, fmt.Errorf("%s: unknown field %q", op.Mnemonic, name)
+			}
+
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	fields := make([]field, len(names))
+	for i, name := range names {
+		fields[i] = fieldVocabulary[name]
+	}
+
+	return fields, nil
+}
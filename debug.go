@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tylerdmace/lc3vm/isa"
+)
+
+// runDebug implements `lc3vm debug image.obj`: a small REPL around
+// isa.VM.StepTraced for stepping the interpreter one instruction at a
+// time and inspecting its state -- the teaching-tool use case called
+// out in this file's package header.
+func runDebug(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: lc3vm debug image.obj")
+		os.Exit(1)
+	}
+
+	vm := isa.NewVM(math.MaxUint16)
+
+	origin, err := loadImage(vm, args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lc3vm: failed to load image %q: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	vm.Reg[isa.RPC] = origin
+
+	d := &debugger{
+		vm:          vm,
+		breakpoints: map[uint16]bool{},
+		watchpoints: map[uint16]bool{},
+	}
+
+	fmt.Printf("lc3vm debug: loaded %q at %#04x\n", args[0], origin)
+	d.repl()
+}
+
+// debugger holds the REPL's state across commands: the VM being
+// stepped, and the breakpoint/watchpoint addresses set with break/watch.
+type debugger struct {
+	vm          *isa.VM
+	breakpoints map[uint16]bool
+	watchpoints map[uint16]bool
+}
+
+func (d *debugger) repl() {
+	scanner := bufio.NewScanner(os.Stdin)
+
+	for {
+		fmt.Print("(lc3db) ")
+
+		if !scanner.Scan() {
+			return
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "step", "s":
+			d.step()
+		case "run", "r":
+			d.run()
+		case "break", "b":
+			d.setBreak(fields[1:])
+		case "watch", "w":
+			d.setWatch(fields[1:])
+		case "regs":
+			d.printRegs()
+		case "mem":
+			d.printMem(fields[1:])
+		case "disasm", "d":
+			d.printDisasm(fields[1:])
+		case "quit", "q":
+			return
+		default:
+			fmt.Printf("unknown command %q\n", fields[0])
+		}
+
+		if d.vm.Halted {
+			fmt.Println("program halted")
+			return
+		}
+	}
+}
+
+// step runs a single traced instruction and reports it.
+func (d *debugger) step() {
+	tr, err := d.vm.StepTraced()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	d.printTrace(tr)
+}
+
+// run steps until the program halts, hits a breakpoint, or faults,
+// reporting any watched address a traced instruction touched along the
+// way.
+func (d *debugger) run() {
+	for !d.vm.Halted {
+		tr, err := d.vm.StepTraced()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		for _, w := range tr.MemWrites {
+			if d.watchpoints[w.Addr] {
+				fmt.Printf("watch: [%#04x] = %#04x\n", w.Addr, w.Value)
+			}
+		}
+
+		if d.breakpoints[tr.PC] {
+			fmt.Printf("breakpoint hit at %#04x\n", tr.PC)
+			return
+		}
+	}
+}
+
+func (d *debugger) setBreak(args []string) {
+	if len(args) != 1 {
+		fmt.Println("usage: break <addr>")
+		return
+	}
+
+	addr, err := parseAddr(args[0])
+	if err != nil {
+		fmt.Printf("bad address %q: %v\n", args[0], err)
+		return
+	}
+
+	d.breakpoints[addr] = true
+	fmt.Printf("breakpoint set at %#04x\n", addr)
+}
+
+func (d *debugger) setWatch(args []string) {
+	if len(args) != 1 {
+		fmt.Println("usage: watch <addr>")
+		return
+	}
+
+	addr, err := parseAddr(args[0])
+	if err != nil {
+		fmt.Printf("bad address %q: %v\n", args[0], err)
+		return
+	}
+
+	d.watchpoints[addr] = true
+	fmt.Printf("watchpoint set at %#04x\n", addr)
+}
+
+func (d *debugger) printRegs() {
+	reg := d.vm.Reg
+	fmt.Printf("PC=%#04x COND=%#04x\n", reg[isa.RPC], reg[isa.RCD])
+	fmt.Printf("R0=%#04x R1=%#04x R2=%#04x R3=%#04x R4=%#04x R5=%#04x R6=%#04x R7=%#04x\n",
+		reg[isa.R0], reg[isa.R1], reg[isa.R2], reg[isa.R3], reg[isa.R4], reg[isa.R5], reg[isa.R6], reg[isa.R7])
+}
+
+func (d *debugger) printMem(args []string) {
+	if len(args) != 2 {
+		fmt.Println("usage: mem <addr> <len>")
+		return
+	}
+
+	addr, err := parseAddr(args[0])
+	if err != nil {
+		fmt.Printf("bad address %q: %v\n", args[0], err)
+		return
+	}
+
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		fmt.Printf("bad length %q: %v\n", args[1], err)
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		a := addr + uint16(i)
+		fmt.Printf("%#04x: %#04x\n", a, d.vm.Read(a))
+	}
+}
+
+func (d *debugger) printDisasm(args []string) {
+	if len(args) != 2 {
+		fmt.Println("usage: disasm <addr> <n>")
+		return
+	}
+
+	addr, err := parseAddr(args[0])
+	if err != nil {
+		fmt.Printf("bad address %q: %v\n", args[0], err)
+		return
+	}
+
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		fmt.Printf("bad count %q: %v\n", args[1], err)
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		a := addr + uint16(i)
+		word := d.vm.Read(a)
+
+		name := "???"
+		if ins, ok := isa.Lookup(word); ok {
+			name = ins.Name
+		}
+
+		fmt.Printf("%#04x: %#04x  %s\n", a, word, name)
+	}
+}
+
+func (d *debugger) printTrace(tr isa.Trace) {
+	fmt.Printf("%#04x: %#04x  %s\n", tr.PC, tr.Instr, tr.Mnemonic)
+
+	for _, w := range tr.MemWrites {
+		fmt.Printf("  [%#04x] = %#04x\n", w.Addr, w.Value)
+	}
+}
+
+// parseAddr parses a hex address, with or without a leading "0x".
+func parseAddr(s string) (uint16, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 16)
+	return uint16(v), err
+}
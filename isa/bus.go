@@ -0,0 +1,69 @@
+package isa
+
+import "fmt"
+
+// MemoryBus is what a VM reads and writes through. Besides plain RAM,
+// it lets a device (the keyboard, the display, a disk controller, a
+// memory-mapped timer, ...) claim an address and intercept access to it
+// instead of the VM having to know the device exists.
+type MemoryBus interface {
+	Read(addr uint16) (uint16, error)
+	Write(addr uint16, value uint16) error
+}
+
+// RAM is the flat MemoryBus backing ordinary program memory.
+type RAM []uint16
+
+// Read returns an error once addr runs off the end of RAM -- this is
+// what stops Step/Run when the PC wanders past the last word.
+func (r RAM) Read(addr uint16) (uint16, error) {
+	if int(addr) >= len(r) {
+		return 0, fmt.Errorf("isa: address %#04x out of range", addr)
+	}
+
+	return r[addr], nil
+}
+
+func (r RAM) Write(addr uint16, value uint16) error {
+	if int(addr) >= len(r) {
+		return fmt.Errorf("isa: address %#04x out of range", addr)
+	}
+
+	r[addr] = value
+
+	return nil
+}
+
+// Mux is a MemoryBus that routes an address to whichever device has
+// registered it, falling back to RAM for everything else.
+type Mux struct {
+	RAM     RAM
+	devices map[uint16]MemoryBus
+}
+
+// NewMux allocates a Mux backed by memSize words of RAM.
+func NewMux(memSize int) *Mux {
+	return &Mux{RAM: make(RAM, memSize), devices: make(map[uint16]MemoryBus)}
+}
+
+// Register claims addr for dev, so future reads/writes to it go to dev
+// instead of RAM.
+func (m *Mux) Register(addr uint16, dev MemoryBus) {
+	m.devices[addr] = dev
+}
+
+func (m *Mux) Read(addr uint16) (uint16, error) {
+	if dev, ok := m.devices[addr]; ok {
+		return dev.Read(addr)
+	}
+
+	return m.RAM.Read(addr)
+}
+
+func (m *Mux) Write(addr uint16, value uint16) error {
+	if dev, ok := m.devices[addr]; ok {
+		return dev.Write(addr, value)
+	}
+
+	return m.RAM.Write(addr, value)
+}
@@ -0,0 +1,56 @@
+package isa
+
+// Keyboard backs the RKBSR/RKBDR memory-mapped registers: reading
+// RKBSR polls stdin non-blockingly, latching a byte into RKBDR and
+// setting the ready bit when one is available. This is what lets a
+// running program (e.g. 2048, rogue) poll the keyboard directly instead
+// of going through a TRAP.
+type Keyboard struct {
+	ready bool
+	data  uint16
+}
+
+func (k *Keyboard) Read(addr uint16) (uint16, error) {
+	switch RegisterMM(addr) {
+	case RKBSR:
+		k.ready = checkKey()
+		if k.ready {
+			k.data = uint16(getChar())
+		}
+
+		if k.ready {
+			return 1 << 15, nil
+		}
+
+		return 0, nil
+	case RKBDR:
+		return k.data, nil
+	}
+
+	return 0, nil
+}
+
+// Write is a no-op -- the keyboard registers are read-only.
+func (k *Keyboard) Write(addr uint16, value uint16) error {
+	return nil
+}
+
+// Display backs the RDSR/RDDR memory-mapped registers: the display is
+// always ready, and writing RDDR flushes a byte to stdout.
+type Display struct{}
+
+func (d *Display) Read(addr uint16) (uint16, error) {
+	if RegisterMM(addr) == RDSR {
+		return 1 << 15, nil
+	}
+
+	return 0, nil
+}
+
+func (d *Display) Write(addr uint16, value uint16) error {
+	if RegisterMM(addr) == RDDR {
+		writeChar(value)
+	}
+
+	return nil
+}
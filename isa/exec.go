@@ -0,0 +1,137 @@
+package isa
+
+// The exec* functions below are the hand-written semantics wired into
+// Table (zinstr.go) by cmd/lc3gen. Each one receives the fully decoded
+// Operands for its instruction and reads only the fields that apply to
+// it.
+
+func execBR(vm *VM, instr uint16) {
+	o := DecodeOperands(instr)
+	if o.NZP&vm.Reg[RCD] != 0x0 {
+		vm.Reg[RPC] += o.PCOffset9
+	}
+}
+
+func execADD(vm *VM, instr uint16) {
+	o := DecodeOperands(instr)
+	if o.IsImm {
+		vm.Reg[o.DR] = vm.Reg[o.SR1] + o.Imm5
+	} else {
+		vm.Reg[o.DR] = vm.Reg[o.SR1] + vm.Reg[o.SR2]
+	}
+
+	vm.UpdateFlag(o.DR)
+}
+
+func execLD(vm *VM, instr uint16) {
+	o := DecodeOperands(instr)
+	vm.Reg[o.DR] = vm.Read(vm.Reg[RPC] + o.PCOffset9)
+	vm.UpdateFlag(o.DR)
+}
+
+func execST(vm *VM, instr uint16) {
+	o := DecodeOperands(instr)
+	vm.Write(vm.Reg[RPC]+o.PCOffset9, vm.Reg[o.SR])
+}
+
+func execJSR(vm *VM, instr uint16) {
+	o := DecodeOperands(instr)
+	vm.Reg[R7] = vm.Reg[RPC]
+
+	if o.IsJSR {
+		vm.Reg[RPC] += o.PCOffset11
+	} else {
+		vm.Reg[RPC] = vm.Reg[o.BaseR]
+	}
+}
+
+func execAND(vm *VM, instr uint16) {
+	o := DecodeOperands(instr)
+	if o.IsImm {
+		vm.Reg[o.DR] = vm.Reg[o.SR1] & o.Imm5
+	} else {
+		vm.Reg[o.DR] = vm.Reg[o.SR1] & vm.Reg[o.SR2]
+	}
+
+	vm.UpdateFlag(o.DR)
+}
+
+func execLDR(vm *VM, instr uint16) {
+	o := DecodeOperands(instr)
+	vm.Reg[o.DR] = vm.Read(vm.Reg[o.BaseR] + o.Offset6)
+	vm.UpdateFlag(o.DR)
+}
+
+func execSTR(vm *VM, instr uint16) {
+	o := DecodeOperands(instr)
+	vm.Write(vm.Reg[o.BaseR]+o.Offset6, vm.Reg[o.SR])
+}
+
+func execRTI(vm *VM, instr uint16) {
+	// Unimplemented -- the LC-3 only enters supervisor mode for
+	// interrupts/traps, neither of which this VM generates.
+}
+
+func execNOT(vm *VM, instr uint16) {
+	o := DecodeOperands(instr)
+	vm.Reg[o.DR] = ^vm.Reg[o.SR1]
+	vm.UpdateFlag(o.DR)
+}
+
+func execLDI(vm *VM, instr uint16) {
+	o := DecodeOperands(instr)
+	vm.Reg[o.DR] = vm.Read(vm.Read(vm.Reg[RPC] + o.PCOffset9))
+	vm.UpdateFlag(o.DR)
+}
+
+func execSTI(vm *VM, instr uint16) {
+	o := DecodeOperands(instr)
+	vm.Write(vm.Read(vm.Reg[RPC]+o.PCOffset9), vm.Reg[o.SR])
+}
+
+func execJMP(vm *VM, instr uint16) {
+	// RET is just JMP R7.
+	o := DecodeOperands(instr)
+	vm.Reg[RPC] = vm.Reg[o.BaseR]
+}
+
+func execRES(vm *VM, instr uint16) {
+	// Unimplemented -- reserved opcode.
+}
+
+func execLEA(vm *VM, instr uint16) {
+	o := DecodeOperands(instr)
+	vm.Reg[o.DR] = vm.Reg[RPC] + o.PCOffset9
+	vm.UpdateFlag(o.DR)
+}
+
+func execTRAP(vm *VM, instr uint16) {
+	o := DecodeOperands(instr)
+
+	switch o.Trapvect8 {
+	case TrapGETC:
+		vm.Reg[R0] = uint16(getChar())
+	case TrapOUT:
+		writeChar(vm.Reg[R0])
+	case TrapPUTS:
+		for addr := vm.Reg[R0]; vm.Read(addr) != 0; addr++ {
+			writeChar(vm.Read(addr))
+		}
+	case TrapIN:
+		writeString("Enter a character: ")
+		c := getChar()
+		writeChar(uint16(c))
+		vm.Reg[R0] = uint16(c)
+	case TrapPUTSP:
+		for addr := vm.Reg[R0]; vm.Read(addr) != 0; addr++ {
+			word := vm.Read(addr)
+			writeChar(word & 0xFF)
+			if hi := word >> 8; hi != 0 {
+				writeChar(hi)
+			}
+		}
+	case TrapHALT:
+		writeString("\r\nHALT\r\n")
+		vm.Halted = true
+	}
+}
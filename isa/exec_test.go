@@ -0,0 +1,241 @@
+package isa
+
+import "testing"
+
+// TestExec runs one instruction word per opcode against a fresh VM and
+// checks the register/memory state it leaves behind. ST/STI/STR cases
+// exist to pin down that their source register decodes from bits
+// 11:9, not 8:6 (a field mix-up with SR1 that silently corrupted every
+// store).
+func TestExec(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(vm *VM)
+		instr uint16
+		check func(t *testing.T, vm *VM)
+	}{
+		{
+			name: "BR taken",
+			setup: func(vm *VM) {
+				vm.Reg[RCD] = uint16(FlZRO)
+			},
+			instr: 0x0405, // BR Z, #5
+			check: func(t *testing.T, vm *VM) {
+				if vm.Reg[RPC] != 0x3005 {
+					t.Errorf("RPC = %#04x, want 0x3005", vm.Reg[RPC])
+				}
+			},
+		},
+		{
+			name: "BR not taken",
+			setup: func(vm *VM) {
+				vm.Reg[RCD] = uint16(FlPOS)
+			},
+			instr: 0x0405, // BR Z, #5
+			check: func(t *testing.T, vm *VM) {
+				if vm.Reg[RPC] != 0x3000 {
+					t.Errorf("RPC = %#04x, want 0x3000 (no branch)", vm.Reg[RPC])
+				}
+			},
+		},
+		{
+			name: "ADD reg",
+			setup: func(vm *VM) {
+				vm.Reg[R0] = 5
+				vm.Reg[R1] = 7
+			},
+			instr: 0x1401, // ADD R2, R0, R1
+			check: func(t *testing.T, vm *VM) {
+				if vm.Reg[R2] != 12 {
+					t.Errorf("R2 = %#04x, want 12", vm.Reg[R2])
+				}
+			},
+		},
+		{
+			name: "ADD imm",
+			setup: func(vm *VM) {
+				vm.Reg[R0] = 5
+			},
+			instr: 0x163F, // ADD R3, R0, #-1
+			check: func(t *testing.T, vm *VM) {
+				if vm.Reg[R3] != 4 {
+					t.Errorf("R3 = %#04x, want 4", vm.Reg[R3])
+				}
+			},
+		},
+		{
+			name: "LD",
+			setup: func(vm *VM) {
+				vm.Write(0x3002, 0xBEEF)
+			},
+			instr: 0x2802, // LD R4, #2
+			check: func(t *testing.T, vm *VM) {
+				if vm.Reg[R4] != 0xBEEF {
+					t.Errorf("R4 = %#04x, want 0xBEEF", vm.Reg[R4])
+				}
+			},
+		},
+		{
+			name: "ST",
+			setup: func(vm *VM) {
+				vm.Reg[R5] = 0x1234
+			},
+			instr: 0x3A03, // ST R5, #3
+			check: func(t *testing.T, vm *VM) {
+				if got := vm.Read(0x3003); got != 0x1234 {
+					t.Errorf("M[0x3003] = %#04x, want 0x1234", got)
+				}
+			},
+		},
+		{
+			name: "JSR",
+			instr: 0x4810, // JSR #0x10
+			check: func(t *testing.T, vm *VM) {
+				if vm.Reg[R7] != 0x3000 {
+					t.Errorf("R7 = %#04x, want 0x3000", vm.Reg[R7])
+				}
+				if vm.Reg[RPC] != 0x3010 {
+					t.Errorf("RPC = %#04x, want 0x3010", vm.Reg[RPC])
+				}
+			},
+		},
+		{
+			name: "JSRR",
+			setup: func(vm *VM) {
+				vm.Reg[R2] = 0x4000
+			},
+			instr: 0x4080, // JSRR R2
+			check: func(t *testing.T, vm *VM) {
+				if vm.Reg[R7] != 0x3000 {
+					t.Errorf("R7 = %#04x, want 0x3000", vm.Reg[R7])
+				}
+				if vm.Reg[RPC] != 0x4000 {
+					t.Errorf("RPC = %#04x, want 0x4000", vm.Reg[RPC])
+				}
+			},
+		},
+		{
+			name: "AND imm",
+			setup: func(vm *VM) {
+				vm.Reg[R0] = 0xFFFF
+			},
+			instr: 0x562F, // AND R3, R0, #15
+			check: func(t *testing.T, vm *VM) {
+				if vm.Reg[R3] != 0x000F {
+					t.Errorf("R3 = %#04x, want 0x000F", vm.Reg[R3])
+				}
+			},
+		},
+		{
+			name: "LDR",
+			setup: func(vm *VM) {
+				vm.Reg[R2] = 0x4000
+				vm.Write(0x4004, 0xCAFE)
+			},
+			instr: 0x6284, // LDR R1, R2, #4
+			check: func(t *testing.T, vm *VM) {
+				if vm.Reg[R1] != 0xCAFE {
+					t.Errorf("R1 = %#04x, want 0xCAFE", vm.Reg[R1])
+				}
+			},
+		},
+		{
+			name: "STR",
+			setup: func(vm *VM) {
+				vm.Reg[R2] = 0x4000
+				vm.Reg[R3] = 0xABCD
+			},
+			instr: 0x7684, // STR R3, R2, #4
+			check: func(t *testing.T, vm *VM) {
+				if got := vm.Read(0x4004); got != 0xABCD {
+					t.Errorf("M[0x4004] = %#04x, want 0xABCD", got)
+				}
+			},
+		},
+		{
+			name: "NOT",
+			setup: func(vm *VM) {
+				vm.Reg[R1] = 0x00FF
+			},
+			instr: 0x907F, // NOT R0, R1
+			check: func(t *testing.T, vm *VM) {
+				if vm.Reg[R0] != 0xFF00 {
+					t.Errorf("R0 = %#04x, want 0xFF00", vm.Reg[R0])
+				}
+			},
+		},
+		{
+			name: "LDI",
+			setup: func(vm *VM) {
+				vm.Write(0x3001, 0x5000)
+				vm.Write(0x5000, 0x9999)
+			},
+			instr: 0xA401, // LDI R2, #1
+			check: func(t *testing.T, vm *VM) {
+				if vm.Reg[R2] != 0x9999 {
+					t.Errorf("R2 = %#04x, want 0x9999", vm.Reg[R2])
+				}
+			},
+		},
+		{
+			name: "STI",
+			setup: func(vm *VM) {
+				vm.Reg[R4] = 0x7777
+				vm.Write(0x3001, 0x5000)
+			},
+			instr: 0xB801, // STI R4, #1
+			check: func(t *testing.T, vm *VM) {
+				if got := vm.Read(0x5000); got != 0x7777 {
+					t.Errorf("M[0x5000] = %#04x, want 0x7777", got)
+				}
+			},
+		},
+		{
+			name: "JMP",
+			setup: func(vm *VM) {
+				vm.Reg[R7] = 0x6000
+			},
+			instr: 0xC1C0, // JMP R7
+			check: func(t *testing.T, vm *VM) {
+				if vm.Reg[RPC] != 0x6000 {
+					t.Errorf("RPC = %#04x, want 0x6000", vm.Reg[RPC])
+				}
+			},
+		},
+		{
+			name:  "LEA",
+			instr: 0xEA10, // LEA R5, #0x10
+			check: func(t *testing.T, vm *VM) {
+				if vm.Reg[R5] != 0x3010 {
+					t.Errorf("R5 = %#04x, want 0x3010", vm.Reg[R5])
+				}
+			},
+		},
+		{
+			name:  "TRAP HALT",
+			instr: 0xF025, // TRAP HALT
+			check: func(t *testing.T, vm *VM) {
+				if !vm.Halted {
+					t.Error("vm.Halted = false, want true")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := NewVM(1 << 16)
+			vm.Reg[RPC] = 0x3000
+
+			if tt.setup != nil {
+				tt.setup(vm)
+			}
+
+			if _, ok := Exec(vm, tt.instr); !ok {
+				t.Fatalf("Exec(%#04x): no instruction matched", tt.instr)
+			}
+
+			tt.check(t, vm)
+		})
+	}
+}
@@ -0,0 +1,240 @@
+// Package isa describes the LC-3 instruction set: the register file and
+// memory bus an instruction operates on (VM), the devices wired onto
+// that bus, and the dispatch table used to execute it.
+//
+// The dispatch table itself (Table, in zinstr.go) is generated by
+// cmd/lc3gen from ops.json -- see that package for the DSL. This file
+// holds the hand-written pieces the generated code depends on: the VM
+// struct, the Instruction type, and the bit-twiddling helpers every
+// Exec function in exec.go uses. The bus and its devices live in
+// bus.go and devices.go.
+package isa
+
+//go:generate go run ../cmd/lc3gen -ops ops.json -out zinstr.go
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Register is a makeshift enum type
+type Register int
+
+const (
+	R0  Register = iota // General purpose
+	R1                  // General purpose
+	R2                  // General purpose
+	R3                  // General purpose
+	R4                  // General purpose
+	R5                  // General purpose
+	R6                  // General purpose
+	R7                  // General purpose
+	RPC                 // Program Counter
+	RCD                 // Conditional
+	RCN                 // Count
+)
+
+// Flag is a makeshift enum type
+type Flag int
+
+const (
+	FlPOS Flag = 1 << 0 // Positive
+	FlZRO Flag = 1 << 1 // Zero
+	FlNEG Flag = 1 << 2 // Negative
+)
+
+// RegisterMM is a makeshift enum for memory-mapped I/O registers
+type RegisterMM int
+
+const (
+	RKBSR RegisterMM = 0xFE00 // Keyboard status
+	RKBDR RegisterMM = 0xFE02 // Keyboard data
+	RDSR  RegisterMM = 0xFE04 // Display status
+	RDDR  RegisterMM = 0xFE06 // Display data
+)
+
+// Trap vectors -- see execTrap in exec.go
+const (
+	TrapGETC  = 0x20 // Read a single character from stdin, no echo
+	TrapOUT   = 0x21 // Write a single character to stdout
+	TrapPUTS  = 0x22 // Write a null-terminated string, one character per word
+	TrapIN    = 0x23 // Print a prompt, read a single character, echo it
+	TrapPUTSP = 0x24 // Write a null-terminated string, two packed characters per word
+	TrapHALT  = 0x25 // Halt execution
+)
+
+// VM is the register file and bus an Instruction's Exec function
+// operates on. The zero value is not ready to run -- use NewVM, which
+// wires up RAM plus the keyboard/display devices.
+type VM struct {
+	Reg [RCN]uint16
+	Bus MemoryBus
+
+	// Halted is set by the HALT trap, an unmatched opcode, or a bus
+	// fault to tell Step/Run to stop.
+	Halted bool
+}
+
+// NewVM allocates a VM with memSize words of RAM and the standard
+// keyboard/display memory-mapped devices registered on top of it.
+func NewVM(memSize int) *VM {
+	mux := NewMux(memSize)
+
+	kb := &Keyboard{}
+	mux.Register(uint16(RKBSR), kb)
+	mux.Register(uint16(RKBDR), kb)
+
+	disp := &Display{}
+	mux.Register(uint16(RDSR), disp)
+	mux.Register(uint16(RDDR), disp)
+
+	return &VM{Bus: mux}
+}
+
+// Instruction is one row of the dispatch table: Name is purely
+// informational (tracing/debugging), Mask/Match identify which bits of
+// a fetched word select this instruction, and Exec carries it out.
+type Instruction struct {
+	Name  string
+	Mask  uint16
+	Match uint16
+	Exec  func(vm *VM, instr uint16)
+}
+
+// Lookup returns the Table entry whose Mask/Match selects instr.
+func Lookup(instr uint16) (Instruction, bool) {
+	for _, ins := range Table {
+		if instr&ins.Mask == ins.Match {
+			return ins, true
+		}
+	}
+
+	return Instruction{}, false
+}
+
+// Exec looks up and runs instr against vm, the single-instruction
+// equivalent of Step for a caller that already has the instruction word
+// in hand -- e.g. package recompile's generated blocks, which bake the
+// word in as a compile-time constant instead of fetching it off the
+// bus. It sets vm.Halted and returns false if instr matches nothing.
+func Exec(vm *VM, instr uint16) (Instruction, bool) {
+	ins, ok := Lookup(instr)
+	if !ok {
+		vm.Halted = true
+		return Instruction{}, false
+	}
+
+	ins.Exec(vm, instr)
+
+	return ins, true
+}
+
+// Step fetches, decodes and executes a single instruction at vm.Reg[RPC].
+// It reports the instruction it ran, or an error if the bus faulted (PC
+// ran off the end of memory) or no instruction matched the fetched word
+// -- either way, vm.Halted is set so the caller knows not to Step again.
+//
+// RPC is incremented right after the fetch, before Exec runs -- the
+// exec* functions that use RPC for PC-relative addressing (BR, LD, ST,
+// JSR, LDI, STI, LEA) assume it already points past the instruction
+// they're running, per the LC-3 spec's instruction cycle.
+func (vm *VM) Step() (Instruction, error) {
+	instr, err := vm.Bus.Read(vm.Reg[RPC])
+	if err != nil {
+		vm.Halted = true
+		return Instruction{}, err
+	}
+
+	vm.Reg[RPC] = vm.Reg[RPC] + 1
+
+	ins, ok := Exec(vm, instr)
+	if !ok {
+		return Instruction{}, fmt.Errorf("isa: no instruction matches %#04x", instr)
+	}
+
+	return ins, nil
+}
+
+// Run steps the VM until it halts or a Step reports an error.
+func (vm *VM) Run() error {
+	for !vm.Halted {
+		if _, err := vm.Step(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read loads the value at address off the bus, discarding the
+// range-checking error -- the fetch in Step already guards against a
+// wild PC, and Exec functions have no way to report a mid-instruction
+// fault of their own.
+func (vm *VM) Read(address uint16) uint16 {
+	value, _ := vm.Bus.Read(address)
+
+	return value
+}
+
+// Write stores value at address on the bus. See Read for why the error
+// is discarded here.
+func (vm *VM) Write(address uint16, value uint16) {
+	vm.Bus.Write(address, value)
+}
+
+// UpdateFlag sets RCD from the sign of vm.Reg[r].
+func (vm *VM) UpdateFlag(r Register) {
+	switch {
+	case vm.Reg[r] == 0x0:
+		vm.Reg[RCD] = uint16(FlZRO)
+	case vm.Reg[r]>>15 == 0x1:
+		vm.Reg[RCD] = uint16(FlNEG)
+	default:
+		vm.Reg[RCD] = uint16(FlPOS)
+	}
+}
+
+// SignExtend sign-extends the low count bits of x to a full uint16.
+func SignExtend(x uint16, count uint) uint16 {
+	if ((x >> (count - 1)) & 0x1) == 0x1 {
+		x |= 0xFFFF << count
+	}
+
+	return x
+}
+
+// Swap byte-swaps x, converting a big-endian LC-3 image word to/from the
+// native representation used by RAM.
+func Swap(x uint16) uint16 {
+	return x<<8 | x>>8
+}
+
+// checkKey reports whether a byte is available on stdin without
+// blocking, so RKBSR can be polled by a running program the way real
+// LC-3 programs (e.g. 2048, rogue) do.
+func checkKey() bool {
+	var fds syscall.FdSet
+	fds.Bits[0] = 1 << uint(os.Stdin.Fd())
+
+	tv := syscall.Timeval{}
+	n, err := syscall.Select(int(os.Stdin.Fd())+1, &fds, nil, nil, &tv)
+
+	return err == nil && n > 0
+}
+
+// getChar blocks for a single byte of input from stdin.
+func getChar() byte {
+	var buf [1]byte
+	os.Stdin.Read(buf[:])
+
+	return buf[0]
+}
+
+func writeChar(c uint16) {
+	fmt.Printf("%c", rune(c))
+}
+
+func writeString(s string) {
+	fmt.Print(s)
+}
@@ -0,0 +1,83 @@
+package isa
+
+import "fmt"
+
+// MemWrite records one write a traced instruction made to the bus.
+type MemWrite struct {
+	Addr  uint16
+	Value uint16
+}
+
+// Trace is the structured result of a single StepTraced call: the
+// instruction's address and raw word, its decoded name/operands, the
+// register file immediately before and after it ran, and any memory
+// writes it made. It exists for the debug REPL -- Step stays the
+// lightweight call the interpreter's hot loop and the recompiler's
+// fallback use.
+type Trace struct {
+	PC         uint16
+	Instr      uint16
+	Mnemonic   string
+	Operands   Operands
+	RegsBefore [RCN]uint16
+	RegsAfter  [RCN]uint16
+	MemWrites  []MemWrite
+}
+
+// traceBus wraps a MemoryBus to record every successful Write made
+// through it, so StepTraced can report what a single instruction
+// touched without every Exec function needing to know it's being
+// watched.
+type traceBus struct {
+	MemoryBus
+	writes *[]MemWrite
+}
+
+func (t traceBus) Write(addr uint16, value uint16) error {
+	if err := t.MemoryBus.Write(addr, value); err != nil {
+		return err
+	}
+
+	*t.writes = append(*t.writes, MemWrite{Addr: addr, Value: value})
+
+	return nil
+}
+
+// StepTraced runs exactly one instruction like Step, but returns a
+// Trace describing it instead of just the looked-up Instruction.
+func (vm *VM) StepTraced() (Trace, error) {
+	addr := vm.Reg[RPC]
+
+	instr, err := vm.Bus.Read(addr)
+	if err != nil {
+		vm.Halted = true
+		return Trace{}, err
+	}
+
+	tr := Trace{
+		PC:         addr,
+		Instr:      instr,
+		Operands:   DecodeOperands(instr),
+		RegsBefore: vm.Reg,
+	}
+
+	vm.Reg[RPC] = vm.Reg[RPC] + 1
+
+	var writes []MemWrite
+	realBus := vm.Bus
+	vm.Bus = traceBus{MemoryBus: realBus, writes: &writes}
+
+	ins, ok := Exec(vm, instr)
+
+	vm.Bus = realBus
+
+	if !ok {
+		return Trace{}, fmt.Errorf("isa: no instruction matches %#04x", instr)
+	}
+
+	tr.Mnemonic = ins.Name
+	tr.RegsAfter = vm.Reg
+	tr.MemWrites = writes
+
+	return tr, nil
+}
@@ -0,0 +1,62 @@
+// Code generated by cmd/lc3gen from ops.json; DO NOT EDIT.
+
+package isa
+
+// Operands is every field any LC-3 instruction can decode out of a raw
+// instruction word. Exec functions only read the fields relevant to
+// their own opcode.
+type Operands struct {
+	BaseR      Register
+	DR         Register
+	Imm5       uint16
+	IsImm      bool
+	IsJSR      bool
+	NZP        uint16
+	Offset6    uint16
+	PCOffset11 uint16
+	PCOffset9  uint16
+	SR         Register
+	SR1        Register
+	SR2        Register
+	Trapvect8  uint16
+}
+
+// DecodeOperands extracts every Operands field from instr.
+func DecodeOperands(instr uint16) Operands {
+	return Operands{
+		BaseR:      Register((instr >> 6) & 0x7),
+		DR:         Register((instr >> 9) & 0x7),
+		Imm5:       SignExtend(instr&0x1F, 5),
+		IsImm:      (instr>>5)&0x1 == 0x1,
+		IsJSR:      (instr>>11)&0x1 == 0x1,
+		NZP:        (instr >> 9) & 0x7,
+		Offset6:    SignExtend(instr&0x3F, 6),
+		PCOffset11: SignExtend(instr&0x7FF, 11),
+		PCOffset9:  SignExtend(instr&0x1FF, 9),
+		SR:         Register((instr >> 9) & 0x7),
+		SR1:        Register((instr >> 6) & 0x7),
+		SR2:        Register(instr & 0x7),
+		Trapvect8:  instr & 0xFF,
+	}
+}
+
+// Table is the instruction dispatch table: Lookup finds the entry whose
+// Mask/Match selects a fetched word, and calls its Exec.
+var Table = []Instruction{
+	{Name: "BR", Mask: 0xF000, Match: 0x0000, Exec: execBR},
+	{Name: "ADD", Mask: 0xF000, Match: 0x1000, Exec: execADD},
+	{Name: "LD", Mask: 0xF000, Match: 0x2000, Exec: execLD},
+	{Name: "ST", Mask: 0xF000, Match: 0x3000, Exec: execST},
+	{Name: "JSR", Mask: 0xF000, Match: 0x4000, Exec: execJSR},
+	{Name: "AND", Mask: 0xF000, Match: 0x5000, Exec: execAND},
+	{Name: "LDR", Mask: 0xF000, Match: 0x6000, Exec: execLDR},
+	{Name: "STR", Mask: 0xF000, Match: 0x7000, Exec: execSTR},
+	{Name: "RTI", Mask: 0xF000, Match: 0x8000, Exec: execRTI},
+	{Name: "NOT", Mask: 0xF000, Match: 0x9000, Exec: execNOT},
+	{Name: "LDI", Mask: 0xF000, Match: 0xA000, Exec: execLDI},
+	{Name: "STI", Mask: 0xF000, Match: 0xB000, Exec: execSTI},
+	{Name: "JMP", Mask: 0xF000, Match: 0xC000, Exec: execJMP},
+	{Name: "RES", Mask: 0xF000, Match: 0xD000, Exec: execRES},
+	{Name: "LEA", Mask: 0xF000, Match: 0xE000, Exec: execLEA},
+	{Name: "TRAP", Mask: 0xF000, Match: 0xF000, Exec: execTRAP},
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"math"
+	"os"
+	"os/exec"
+	"os/signal"
+
+	"github.com/tylerdmace/lc3vm/isa"
+	"github.com/tylerdmace/lc3vm/recompile"
 )
 
 // lc3vm - Tyler Mace <tyler@madhive.com>
@@ -11,87 +18,66 @@ import (
 //  - Simulates the LC-3 educational computer platform
 //  - The ISA is small but includes most of the common features
 //	  found in modern architectures
-
-// Register is a makeshift enum type
-type Register int
-
-const (
-	r0  Register = iota // General purpose
-	r1                  // General purpose
-	r2                  // General purpose
-	r3                  // General purpose
-	r4                  // General purpose
-	r5                  // General purpose
-	r6                  // General purpose
-	r7                  // General purpose
-	rPC                 // Program Counter
-	rCD                 // Conditional
-	rCN                 // Count
-)
-
-// Flag is a makeshift enum type
-type Flag int
+//  - The instruction set itself (registers, opcodes, the dispatch
+//    table and the I/O it's wired to) lives in package isa; this file
+//    is just the fetch loop and the CLI around it.
 
 const (
-	flPOS Flag = 1 << 0 // Positive
-	flZRO Flag = 1 << 1 // Zero
-	flNEG Flag = 1 << 2 // Negative
+	pcStart = 0x3000 // OS space < 3000 -- everything else can be used as program memory
 )
 
-// OpCode is a makeshift enum type
-type OpCode int
-
-const ( // Order is important: BR = 0000, ADD = 0001, LD = 0010, ...
-	opBR  OpCode = iota // Branch
-	opADD               // Add
-	opLD                // Load
-	opST                // Store
-	opJSR               // Jump register
-	opAND               // Bitwise and
-	opLDR               // Load register
-	opSTR               // Store register
-	opRTI               // Return from inturrupt (Unimplemented)
-	opNOT               // Bitwise not
-	opLDI               // Load indirect
-	opSTI               // Store indirect
-	opJMP               // Jump
-	opRES               // Reserve (Unimplemented)
-	opLEA               // Load effective address
-	opTRA               // Trap (Clock resets and halt operations, for example)
-)
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "recompile" {
+		runRecompile(os.Args[2:])
+		return
+	}
 
-// RegisterMM is a makeshift enum
-type RegisterMM int
+	if len(os.Args) > 1 && os.Args[1] == "debug" {
+		runDebug(os.Args[2:])
+		return
+	}
 
-const (
-	rKBSR RegisterMM = 0xFE00
-	rKBDR RegisterMM = 0xFE02
-)
+	imagePath := flag.String("image", "", "path to an LC-3 .obj program image to load (overrides the built-in demo instructions)")
+	flag.Parse()
 
-const (
-	pcStart = 0x3000 // OS space < 3000 -- everything else can be used as program memory
-)
+	// The available memory in the LC-3 is limited to 128kb (65k addressable locations)
+	vm := isa.NewVM(math.MaxUint16)
 
-// Memory
+	if *imagePath != "" {
+		origin, err := loadImage(vm, *imagePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lc3vm: failed to load image %q: %v\r\n", *imagePath, err)
+			os.Exit(1)
+		}
 
-var memory []uint16
-var registers []uint16
+		vm.Reg[isa.RPC] = origin
+	} else {
+		// Start our execution (PC) at 0x3000
+		vm.Reg[isa.RPC] = pcStart
 
-func main() {
-	memory = make([]uint16, math.MaxUint16) // The available memory in the LC-3 is limited to 128kb (65k addressable locations)
-	registers = make([]uint16, rCN)
+		// Hardcode an initial instruction at rPC
+		vm.Write(pcStart, 0x1001) // This instruction is an add instruction that adds values from r0 and r1 and stores back in r0
+		vm.Write(pcStart+1, 0x1001)
+		vm.Write(pcStart+2, 0x1001)
+		vm.Write(pcStart+3, 0x1024) // This instruction uses the imm scalar value 4 instead of r1 as its second operand resulting in r0 += 4
 
-	// Start our execution (PC) at 0x3000
-	registers[rPC] = pcStart
+		// ... and set some initial values in registers
+		vm.Reg[isa.R1] = 0x1
+	}
 
-	// Hardcode an initial instruction at rPC + 1
-	memory[pcStart+1] = 0x1001 // This instruction is an add instruction that adds values from r0 and r1 and stores back in r0
-	memory[pcStart+2] = 0x1001
-	memory[pcStart+3] = 0x1001
-	memory[pcStart+4] = 0x1024 // This instruction uses the imm scalar value 4 instead of r1 as its second operand resulting in r0 += 4
+	// Put the terminal into cbreak/no-echo mode so GETC/IN and the KBSR
+	// poll can read keystrokes as they arrive, then restore it on any exit
+	// path (normal halt or Ctrl-C).
+	disableInputBuffering()
+	defer restoreInputBuffering()
 
-	// ... and set some initial values in registers
-	registers[r1] = 0x1
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		<-sigs
+		restoreInputBuffering()
+		os.Exit(2)
+	}()
 
 	// Execution procedure:
 	// 1. Load instruction from memory at the address held by PC
@@ -101,95 +87,103 @@ func main() {
 	// 5. Start over
 
 	// Event loop
-	running := true
 	for {
-		registers[rPC] = registers[rPC] + 1
-
-		if registers[rPC] >= (uint16)(len(memory)) {
+		ins, err := vm.Step()
+		if err != nil {
+			fmt.Println(err)
 			break
 		}
 
-		// Fetch
-		instruction := read(registers[rPC])
-
-		// Decode
-		op, dst, srcA, flag, srcB := decode(instruction)
-
-		if op != 0x0 { // Dont log no-ops
-			fmt.Printf("Instruction: %b\r\nOperation: %d\r\nOperands: %d, %d, %d, %d\r\n", instruction, op, dst, srcA, flag, srcB)
-		}
-
-		// Execute
-		switch op {
-		case 0x0: // Branch
-		case 0x1: // Add
-			// f(a,b) == a+b
-			if flag == 0x1 { // We know that the srcB is a 5-bit unsigned int used as immediate scalar
-				registers[dst] = registers[srcA] + srcB
-			} else {
-				registers[dst] = registers[srcA] + registers[srcB]
-			}
-
-			updateFlag(dst)
-		case 0x2: // Load
-		case 0x3: // Store
-		case 0x4: // Jump register
-		case 0x5: // Bitwise and
-		case 0x6: // Load register
-		case 0x7: // Store register
-		case 0x8: // Return from interrupt
-		case 0x9: // Bitwise not
-		case 0xA: // Load indirect
-		case 0xB: // Store indirect
-		case 0xC: // Jump
-		case 0xD: // Unused -- can use for testing
-		case 0xE: // Load effective address
-		case 0xF: // Trap
-			running = false
-		default: // OpCode == HCF ;)
-			fmt.Println("Halting & catching fire...")
-			running = false
+		if ins.Name != "BR" { // Dont log no-ops
+			fmt.Printf("Operation: %s\r\n", ins.Name)
 		}
 
 		// Exit our event loop
-		if !running {
+		if vm.Halted {
 			break
 		}
 	}
 
-	fmt.Printf("Registers: %X\r\n", registers)
+	fmt.Printf("Registers: %X\r\n", vm.Reg)
 }
 
-func decode(r uint16) (uint16, uint16, uint16, uint16, uint16) {
-	return r >> 12, (r >> 9) & 0x7, (r >> 6) & 0x7, (r >> 5) & 0x1, r & 0x1F
-}
+// loadImage reads a standard LC-3 .obj file at path into the VM's bus
+// and returns the program's origin address. The first 16-bit word in
+// the file is the origin, and every word (including the origin itself)
+// is stored big-endian and must be byte-swapped.
+func loadImage(vm *isa.VM, path string) (uint16, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
 
-func read(address uint16) uint16 {
-	return memory[address]
-}
+	if len(data) < 2 || len(data)%2 != 0 {
+		return 0, fmt.Errorf("malformed image: odd or empty byte length %d", len(data))
+	}
+
+	words := make([]uint16, len(data)/2)
+	for i := range words {
+		words[i] = isa.Swap(uint16(data[2*i]) | uint16(data[2*i+1])<<8)
+	}
+
+	origin := words[0]
+	for i, w := range words[1:] {
+		vm.Write(origin+uint16(i), w)
+	}
 
-func write(address uint16, value uint16) {
-	memory[address] = value
+	return origin, nil
 }
 
-func signExtend(x uint16, count uint) uint16 {
-	if ((x >> (count - 1)) & 0x1) == 0x1 {
-		x = (0xFFFF << count)
+// runRecompile implements `lc3vm recompile [-out file] [-pkg name]
+// image.obj`: it loads image.obj, statically discovers its basic blocks
+// from the entry point, and writes the generated Go translation to
+// -out. It does not run the result -- build and run the generated file
+// the way you would any other Go program.
+func runRecompile(args []string) {
+	fs := flag.NewFlagSet("recompile", flag.ExitOnError)
+	outPath := fs.String("out", "lc3out.go", "path to write the generated Go source")
+	pkgName := fs.String("pkg", "main", "package name for the generated source")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: lc3vm recompile [-out file] [-pkg name] image.obj")
+		os.Exit(1)
 	}
 
-	return x
+	vm := isa.NewVM(math.MaxUint16)
+
+	origin, err := loadImage(vm, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lc3vm: failed to load image %q: %v\r\n", fs.Arg(0), err)
+		os.Exit(1)
+	}
+
+	blocks := recompile.Discover(vm, origin)
+
+	out, err := os.Create(*outPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lc3vm: %v\r\n", err)
+		os.Exit(1)
+	}
+	defer out.Close()
+
+	if err := recompile.Generate(out, *pkgName, blocks, origin); err != nil {
+		fmt.Fprintf(os.Stderr, "lc3vm: %v\r\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("lc3vm: recompiled %d basic blocks to %s\n", len(blocks), *outPath)
 }
 
-func swap(x uint16) uint16 {
-	return x<<8 | x>>8
+// disableInputBuffering puts the controlling terminal into cbreak mode
+// with echo disabled, so a single keystroke is available to read() /
+// getChar() as soon as it's typed.
+func disableInputBuffering() {
+	exec.Command("stty", "-F", "/dev/tty", "cbreak", "-echo").Run()
 }
 
-func updateFlag(f uint16) {
-	if registers[f] == 0x0 {
-		registers[rCD] = (uint16)(flZRO)
-	} else if registers[f]>>15 == 0x1 {
-		registers[rCD] = (uint16)(flNEG)
-	} else {
-		registers[rCD] = (uint16)(flPOS)
-	}
+// restoreInputBuffering undoes disableInputBuffering, restoring the
+// terminal's normal line-buffered, echoing mode.
+func restoreInputBuffering() {
+	exec.Command("stty", "-F", "/dev/tty", "sane").Run()
 }
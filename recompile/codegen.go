@@ -0,0 +1,260 @@
+package recompile
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+
+	"github.com/tylerdmace/lc3vm/isa"
+)
+
+// Generate emits a Go source file defining one function per block in
+// blocks, a Blocks dispatch table keyed by start address, and a Run
+// trampoline that drives the VM through them -- falling back to
+// vm.Step() for any address Blocks doesn't cover, which is how
+// TRAP-adjacent interpreter hand-off and indirect jumps are handled.
+//
+// Each block function translates its instructions to the same register
+// reads/writes and branch tests exec.go's exec* functions perform --
+// not a call back into isa.Exec -- so straight-line code runs as native
+// Go instead of re-walking isa.Table on every instruction. Writes into
+// a generated block's address range invalidate that block (see
+// writeInvalidatingBus) so self-modifying code falls back to the
+// interpreter instead of re-running stale translated instructions.
+func Generate(w io.Writer, pkgName string, blocks map[uint16]*Block, entry uint16) error {
+	starts := make([]uint16, 0, len(blocks))
+	for start := range blocks {
+		starts = append(starts, start)
+	}
+
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by cmd/lc3vm recompile; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", pkgName)
+	fmt.Fprintf(&src, "import \"github.com/tylerdmace/lc3vm/isa\"\n\n")
+
+	for _, start := range starts {
+		writeBlockFunc(&src, blocks[start])
+	}
+
+	fmt.Fprintf(&src, "// Blocks maps a block's start address to its generated function.\n")
+	fmt.Fprintf(&src, "var Blocks = map[uint16]func(*isa.VM) uint16{\n")
+	for _, start := range starts {
+		fmt.Fprintf(&src, "\t0x%04X: %s,\n", start, blockFuncName(start))
+	}
+	fmt.Fprintf(&src, "}\n\n")
+
+	writeBlockOwners(&src, blocks, starts)
+
+	fmt.Fprintf(&src, "// Entry is the image's origin address, where Run starts.\n")
+	fmt.Fprintf(&src, "const Entry = 0x%04X\n\n", entry)
+
+	fmt.Fprintf(&src, `// Run drives vm through the compiled Blocks starting at Entry, falling
+// back to the bytecode interpreter (vm.Step) one instruction at a time
+// for any address Blocks doesn't cover -- an indirect jump/call target,
+// or a code page a STR/STI overwrote after Blocks was generated.
+func Run(vm *isa.VM) error {
+	vm.Bus = &invalidatingBus{MemoryBus: vm.Bus}
+
+	pc := uint16(Entry)
+
+	for !vm.Halted {
+		if fn, ok := Blocks[pc]; ok {
+			pc = fn(vm)
+			continue
+		}
+
+		vm.Reg[isa.RPC] = pc
+		if _, err := vm.Step(); err != nil {
+			return err
+		}
+
+		pc = vm.Reg[isa.RPC]
+	}
+
+	return nil
+}
+
+// signExtend mirrors isa.SignExtend: block functions work from raw
+// bit-fields baked in as constants rather than a decoded
+// isa.Operands, so they need their own copy to sign-extend PCoffset9,
+// PCoffset11, Imm5 and Offset6 the same way exec.go's exec* functions
+// do.
+func signExtend(x uint16, count uint) uint16 {
+	if (x>>(count-1))&0x1 == 0x1 {
+		x |= 0xFFFF << count
+	}
+
+	return x
+}
+
+// invalidatingBus wraps the VM's real bus so a write into any address a
+// compiled block covers evicts that block from Blocks -- the next time
+// pc reaches it, Run falls back to vm.Step(), which always sees
+// current memory. This is what keeps self-modifying code correct: a
+// block, once invalidated, simply never runs compiled again.
+type invalidatingBus struct {
+	isa.MemoryBus
+}
+
+func (b *invalidatingBus) Write(addr uint16, value uint16) error {
+	if owner, ok := blockOwners[addr]; ok {
+		delete(Blocks, owner)
+	}
+
+	return b.MemoryBus.Write(addr, value)
+}
+`)
+
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		return fmt.Errorf("recompile: generated invalid Go: %w", err)
+	}
+
+	_, err = w.Write(out)
+
+	return err
+}
+
+// writeBlockOwners emits blockOwners, mapping every address any block
+// covers back to that block's start address, so invalidatingBus can
+// find and evict the right entry in Blocks on a write.
+func writeBlockOwners(src *bytes.Buffer, blocks map[uint16]*Block, starts []uint16) {
+	fmt.Fprintf(src, "// blockOwners maps every address a compiled block covers back to\n")
+	fmt.Fprintf(src, "// that block's start address, for invalidatingBus.\n")
+	fmt.Fprintf(src, "var blockOwners = map[uint16]uint16{\n")
+
+	for _, start := range starts {
+		for _, in := range blocks[start].Instrs {
+			fmt.Fprintf(src, "\t0x%04X: 0x%04X,\n", in.Addr, start)
+		}
+	}
+
+	fmt.Fprintf(src, "}\n\n")
+}
+
+// writeBlockFunc emits one block's generated function: every
+// instruction but the last translates straight to the same register
+// reads/writes its exec* function in isa/exec.go performs; the last
+// carries out the block's terminator (a conditional branch, a call, or
+// an indirect jump) and returns the next block's start address.
+func writeBlockFunc(src *bytes.Buffer, blk *Block) {
+	fmt.Fprintf(src, "func %s(vm *isa.VM) uint16 {\n", blockFuncName(blk.Start))
+
+	last := len(blk.Instrs) - 1
+	for i, in := range blk.Instrs {
+		fmt.Fprintf(src, "\tvm.Reg[isa.RPC] = 0x%04X\n", in.Addr+1)
+
+		if i == last {
+			writeTerminator(src, in, blk)
+		} else {
+			writeInstr(src, in)
+			fmt.Fprintf(src, "\tif vm.Halted {\n\t\treturn 0\n\t}\n")
+		}
+	}
+
+	fmt.Fprintf(src, "}\n\n")
+}
+
+// writeInstr translates a single non-terminator instruction to Go,
+// mirroring the corresponding exec* function in isa/exec.go. vm.Reg[RPC]
+// is assumed to already hold the address past in.Addr, exactly as it
+// would going into isa.Exec from isa.VM.Step.
+func writeInstr(src *bytes.Buffer, in Instr) {
+	word := in.Word
+	o := isa.DecodeOperands(word)
+
+	switch word >> 12 {
+	case 0x1: // ADD
+		writeALU(src, "+", word, o)
+	case 0x2: // LD
+		fmt.Fprintf(src, "\tvm.Reg[%s] = vm.Read(vm.Reg[isa.RPC] + signExtend(0x%03X, 9))\n", reg(o.DR), word&0x1FF)
+		fmt.Fprintf(src, "\tvm.UpdateFlag(%s)\n", reg(o.DR))
+	case 0x3: // ST
+		fmt.Fprintf(src, "\tvm.Write(vm.Reg[isa.RPC]+signExtend(0x%03X, 9), vm.Reg[%s])\n", word&0x1FF, reg(o.SR))
+	case 0x5: // AND
+		writeALU(src, "&", word, o)
+	case 0x6: // LDR
+		fmt.Fprintf(src, "\tvm.Reg[%s] = vm.Read(vm.Reg[%s] + signExtend(0x%02X, 6))\n", reg(o.DR), reg(o.BaseR), word&0x3F)
+		fmt.Fprintf(src, "\tvm.UpdateFlag(%s)\n", reg(o.DR))
+	case 0x7: // STR
+		fmt.Fprintf(src, "\tvm.Write(vm.Reg[%s]+signExtend(0x%02X, 6), vm.Reg[%s])\n", reg(o.BaseR), word&0x3F, reg(o.SR))
+	case 0x8: // RTI -- unimplemented, same as exec.go's execRTI
+	case 0x9: // NOT
+		fmt.Fprintf(src, "\tvm.Reg[%s] = ^vm.Reg[%s]\n", reg(o.DR), reg(o.SR1))
+		fmt.Fprintf(src, "\tvm.UpdateFlag(%s)\n", reg(o.DR))
+	case 0xA: // LDI
+		fmt.Fprintf(src, "\tvm.Reg[%s] = vm.Read(vm.Read(vm.Reg[isa.RPC] + signExtend(0x%03X, 9)))\n", reg(o.DR), word&0x1FF)
+		fmt.Fprintf(src, "\tvm.UpdateFlag(%s)\n", reg(o.DR))
+	case 0xB: // STI
+		fmt.Fprintf(src, "\tvm.Write(vm.Read(vm.Reg[isa.RPC]+signExtend(0x%03X, 9)), vm.Reg[%s])\n", word&0x1FF, reg(o.SR))
+	case 0xD: // RES -- unimplemented, same as exec.go's execRES
+	case 0xE: // LEA
+		fmt.Fprintf(src, "\tvm.Reg[%s] = vm.Reg[isa.RPC] + signExtend(0x%03X, 9)\n", reg(o.DR), word&0x1FF)
+		fmt.Fprintf(src, "\tvm.UpdateFlag(%s)\n", reg(o.DR))
+	case 0xF: // TRAP -- GETC/OUT/PUTS/IN/PUTSP read or write the console,
+		// which isn't register arithmetic to translate; run it through
+		// isa.Exec like the interpreter does. HALT is handled in
+		// writeTerminator, since it always ends its block.
+		fmt.Fprintf(src, "\tisa.Exec(vm, 0x%04X)\n", word)
+	}
+}
+
+// writeALU emits an ADD/AND, which share the same DR/SR1/(SR2 or Imm5)
+// shape and only differ in their operator.
+func writeALU(src *bytes.Buffer, op string, word uint16, o isa.Operands) {
+	if o.IsImm {
+		fmt.Fprintf(src, "\tvm.Reg[%s] = vm.Reg[%s] %s signExtend(0x%02X, 5)\n", reg(o.DR), reg(o.SR1), op, word&0x1F)
+	} else {
+		fmt.Fprintf(src, "\tvm.Reg[%s] = vm.Reg[%s] %s vm.Reg[%s]\n", reg(o.DR), reg(o.SR1), op, reg(o.SR2))
+	}
+
+	fmt.Fprintf(src, "\tvm.UpdateFlag(%s)\n", reg(o.DR))
+}
+
+// writeTerminator emits the instruction that ends blk: a conditional
+// BR tested against a mirrored NZP mask, a JSR/JSRR call, a JMP, a
+// TRAP HALT, or (for TermFallthrough) just a normal instruction
+// followed by a jump to the next block.
+func writeTerminator(src *bytes.Buffer, in Instr, blk *Block) {
+	switch blk.Term {
+	case TermBranch:
+		o := isa.DecodeOperands(in.Word)
+		fmt.Fprintf(src, "\tif vm.Reg[isa.RCD]&0x%X != 0 {\n", o.NZP)
+		fmt.Fprintf(src, "\t\tvm.Reg[isa.RPC] = vm.Reg[isa.RPC] + signExtend(0x%03X, 9)\n", in.Word&0x1FF)
+		fmt.Fprintf(src, "\t\treturn vm.Reg[isa.RPC]\n")
+		fmt.Fprintf(src, "\t}\n")
+		fmt.Fprintf(src, "\treturn vm.Reg[isa.RPC]\n")
+	case TermJump: // JSR -- static target
+		fmt.Fprintf(src, "\tvm.Reg[isa.R7] = vm.Reg[isa.RPC]\n")
+		fmt.Fprintf(src, "\tvm.Reg[isa.RPC] = vm.Reg[isa.RPC] + signExtend(0x%03X, 11)\n", in.Word&0x7FF)
+		fmt.Fprintf(src, "\treturn vm.Reg[isa.RPC]\n")
+	case TermIndirect:
+		o := isa.DecodeOperands(in.Word)
+		if in.Word>>12 == 0x4 { // JSRR
+			fmt.Fprintf(src, "\tvm.Reg[isa.R7] = vm.Reg[isa.RPC]\n")
+		}
+		fmt.Fprintf(src, "\tvm.Reg[isa.RPC] = vm.Reg[%s]\n", reg(o.BaseR))
+		fmt.Fprintf(src, "\treturn vm.Reg[isa.RPC]\n")
+	case TermHalt:
+		fmt.Fprintf(src, "\tisa.Exec(vm, 0x%04X)\n", in.Word)
+		fmt.Fprintf(src, "\treturn 0\n")
+	case TermFallthrough:
+		writeInstr(src, in)
+		fmt.Fprintf(src, "\tif vm.Halted {\n\t\treturn 0\n\t}\n")
+		fmt.Fprintf(src, "\treturn 0x%04X\n", blk.Targets[0])
+	}
+}
+
+// reg names a general-purpose register operand for use in generated
+// source, e.g. "isa.R3".
+func reg(r isa.Register) string {
+	return fmt.Sprintf("isa.R%d", r)
+}
+
+func blockFuncName(addr uint16) string {
+	return fmt.Sprintf("Block%04X", addr)
+}
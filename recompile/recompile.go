@@ -0,0 +1,185 @@
+// Package recompile statically translates a loaded LC-3 image into
+// native Go. Starting from an entry address, it follows BR/JSR/JMP
+// targets to discover the program's basic blocks (Discover), then
+// emits one Go function per block plus a trampoline that falls back to
+// the interpreter for indirect control flow (Generate).
+//
+// This mirrors the approach of statically recompiling an NES ROM:
+// discover reachable code by following control flow, translate
+// straight-line runs to native functions, and punt on anything whose
+// target isn't known until runtime.
+package recompile
+
+import "github.com/tylerdmace/lc3vm/isa"
+
+// Terminator classifies how a Block's control flow leaves it.
+type Terminator int
+
+const (
+	// TermFallthrough: the block ends because the next address is
+	// another block's start, not because of a branch/jump instruction.
+	TermFallthrough Terminator = iota
+	// TermBranch: a conditional BR -- Targets holds [taken, not-taken].
+	TermBranch
+	// TermJump: an unconditional static successor (JSR, or BR NZP=111).
+	TermJump
+	// TermIndirect: JMP/JSRR -- the target is a runtime register value.
+	TermIndirect
+	// TermHalt: a HALT trap. No successors.
+	TermHalt
+)
+
+// Instr is one instruction within a Block, at its absolute address.
+type Instr struct {
+	Addr uint16
+	Word uint16
+}
+
+// Block is a maximal run of straight-line code: execution always
+// enters at Start and always leaves after the last Instr, to one of
+// Targets (for TermBranch/TermJump/TermFallthrough) or to a
+// runtime-computed address (TermIndirect/TermHalt).
+type Block struct {
+	Start   uint16
+	Instrs  []Instr
+	Term    Terminator
+	Targets []uint16
+}
+
+// Discover walks the control flow reachable from entry and returns one
+// Block per discovered start address, keyed by that address.
+//
+// It runs in two passes: the first follows BR/JSR/JMP to find every
+// address control flow can land on (including fallthrough/return
+// sites); the second re-walks straight-line from each of those with the
+// full set of start addresses already known, so a block never runs past
+// a boundary another block also starts at.
+func Discover(vm *isa.VM, entry uint16) map[uint16]*Block {
+	starts := findBlockStarts(vm, entry)
+
+	blocks := make(map[uint16]*Block, len(starts))
+	for start := range starts {
+		blocks[start] = scanBlock(vm, start, starts)
+	}
+
+	return blocks
+}
+
+// findBlockStarts follows control flow from entry, returning every
+// address a block can start at.
+func findBlockStarts(vm *isa.VM, entry uint16) map[uint16]bool {
+	starts := map[uint16]bool{entry: true}
+	visited := map[uint16]bool{}
+	worklist := []uint16{entry}
+
+	for len(worklist) > 0 {
+		addr := worklist[0]
+		worklist = worklist[1:]
+
+		for {
+			if visited[addr] {
+				break
+			}
+
+			visited[addr] = true
+			word := vm.Read(addr)
+
+			switch next, targets, stop := classify(addr, word); {
+			case stop:
+				for _, t := range targets {
+					if !starts[t] {
+						starts[t] = true
+						worklist = append(worklist, t)
+					}
+				}
+			default:
+				addr = next
+				continue
+			}
+
+			break
+		}
+	}
+
+	return starts
+}
+
+// scanBlock walks straight-line from start until it hits a terminator
+// or the start of another known block, producing the finished Block.
+func scanBlock(vm *isa.VM, start uint16, starts map[uint16]bool) *Block {
+	blk := &Block{Start: start}
+
+	addr := start
+	for {
+		word := vm.Read(addr)
+		blk.Instrs = append(blk.Instrs, Instr{Addr: addr, Word: word})
+
+		next, targets, stop := classify(addr, word)
+		if stop {
+			blk.Term, blk.Targets = terminatorFor(word), targets
+			return blk
+		}
+
+		if starts[next] {
+			blk.Term = TermFallthrough
+			blk.Targets = []uint16{next}
+			return blk
+		}
+
+		addr = next
+	}
+}
+
+// classify decodes the instruction word at addr and reports: the next
+// address to scan if this isn't a terminator (next), the statically
+// known successor addresses if it is (targets), and whether it is one
+// (stop).
+func classify(addr uint16, word uint16) (next uint16, targets []uint16, stop bool) {
+	op := word >> 12
+
+	switch op {
+	case 0x0: // BR -- NZP==0 never branches, so it's effectively a no-op
+		nzp := (word >> 9) & 0x7
+		if nzp == 0 {
+			return addr + 1, nil, false
+		}
+
+		target := addr + 1 + isa.SignExtend(word&0x1FF, 9)
+
+		return 0, []uint16{target, addr + 1}, true
+	case 0x4: // JSR/JSRR
+		if (word>>11)&0x1 == 0x1 { // JSR -- static target, plus its return site
+			target := addr + 1 + isa.SignExtend(word&0x7FF, 11)
+			return 0, []uint16{target, addr + 1}, true
+		}
+
+		return 0, nil, true // JSRR -- indirect
+	case 0xC: // JMP/RET -- always indirect
+		return 0, nil, true
+	case 0xF: // TRAP -- HALT ends the block, everything else falls through
+		if word&0xFF == isa.TrapHALT {
+			return 0, nil, true
+		}
+
+		return addr + 1, nil, false
+	default:
+		return addr + 1, nil, false
+	}
+}
+
+// terminatorFor reports the Terminator a stopping instruction word ends
+// its block with.
+func terminatorFor(word uint16) Terminator {
+	switch op := word >> 12; {
+	case op == 0x0:
+		return TermBranch
+	case op == 0x4 && (word>>11)&0x1 == 0x1:
+		return TermJump
+	case op == 0x4, op == 0xC:
+		return TermIndirect
+	case op == 0xF:
+		return TermHalt
+	default:
+		return TermFallthrough
+	}
+}
@@ -0,0 +1,146 @@
+package recompile
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tylerdmace/lc3vm/isa"
+)
+
+// TestClassify exercises classify's decisions for the instructions that
+// drive block discovery: a no-op BR falls through instead of stopping,
+// JSR stops but also discovers its return site, and JMP/JSRR stop with
+// no statically known target at all.
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name        string
+		addr        uint16
+		word        uint16
+		wantNext    uint16
+		wantTargets []uint16
+		wantStop    bool
+	}{
+		{
+			name:     "BR NZP=0 falls through",
+			addr:     0x3000,
+			word:     0x0000, // BR nzp=0, #0
+			wantNext: 0x3001,
+			wantStop: false,
+		},
+		{
+			name:        "BR taken/not-taken both stop the block",
+			addr:        0x3000,
+			word:        0x0405, // BR Z, #5
+			wantTargets: []uint16{0x3006, 0x3001},
+			wantStop:    true,
+		},
+		{
+			name:        "JSR stops and discovers its return site",
+			addr:        0x3000,
+			word:        0x4810, // JSR #0x10
+			wantTargets: []uint16{0x3011, 0x3001},
+			wantStop:    true,
+		},
+		{
+			name:        "JSRR stops with no static target",
+			addr:        0x3000,
+			word:        0x4080, // JSRR R2
+			wantTargets: nil,
+			wantStop:    true,
+		},
+		{
+			name:        "JMP stops with no static target",
+			addr:        0x3000,
+			word:        0xC1C0, // JMP R7
+			wantTargets: nil,
+			wantStop:    true,
+		},
+		{
+			name:        "TRAP HALT stops the block",
+			addr:        0x3000,
+			word:        0xF025, // TRAP HALT
+			wantTargets: nil,
+			wantStop:    true,
+		},
+		{
+			name:     "TRAP GETC falls through",
+			addr:     0x3000,
+			word:     0xF020, // TRAP GETC
+			wantNext: 0x3001,
+			wantStop: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, targets, stop := classify(tt.addr, tt.word)
+
+			if stop != tt.wantStop {
+				t.Fatalf("stop = %v, want %v", stop, tt.wantStop)
+			}
+
+			if !stop && next != tt.wantNext {
+				t.Errorf("next = %#04x, want %#04x", next, tt.wantNext)
+			}
+
+			if stop && !reflect.DeepEqual(targets, tt.wantTargets) {
+				t.Errorf("targets = %v, want %v", targets, tt.wantTargets)
+			}
+		})
+	}
+}
+
+// TestDiscoverJSRReturnSite checks that Discover splits a block at a
+// JSR's return site even though nothing in the image branches there
+// directly -- the call itself makes it reachable.
+func TestDiscoverJSRReturnSite(t *testing.T) {
+	vm := newTestVM(map[uint16]uint16{
+		0x3000: 0x4802, // JSR #2      -> call 0x3003, return to 0x3001
+		0x3001: 0xF025, // TRAP HALT   -- the return site
+		0x3003: 0xF025, // TRAP HALT   -- the call target
+	})
+
+	blocks := Discover(vm, 0x3000)
+
+	for _, start := range []uint16{0x3000, 0x3001, 0x3003} {
+		if _, ok := blocks[start]; !ok {
+			t.Errorf("blocks[%#04x] missing, want a discovered block there", start)
+		}
+	}
+
+	if got := blocks[0x3000].Term; got != TermJump {
+		t.Errorf("blocks[0x3000].Term = %v, want TermJump", got)
+	}
+}
+
+// TestDiscoverIndirectTerminatesWithoutTargets checks that JMP/JSRR end
+// a block without Discover inventing a target to keep walking from.
+func TestDiscoverIndirectTerminatesWithoutTargets(t *testing.T) {
+	vm := newTestVM(map[uint16]uint16{
+		0x3000: 0xC1C0, // JMP R7
+	})
+
+	blocks := Discover(vm, 0x3000)
+
+	if len(blocks) != 1 {
+		t.Fatalf("len(blocks) = %d, want 1", len(blocks))
+	}
+
+	blk := blocks[0x3000]
+	if blk.Term != TermIndirect {
+		t.Errorf("Term = %v, want TermIndirect", blk.Term)
+	}
+
+	if blk.Targets != nil {
+		t.Errorf("Targets = %v, want nil", blk.Targets)
+	}
+}
+
+func newTestVM(image map[uint16]uint16) *isa.VM {
+	vm := isa.NewVM(1 << 16)
+	for addr, word := range image {
+		vm.Write(addr, word)
+	}
+
+	return vm
+}